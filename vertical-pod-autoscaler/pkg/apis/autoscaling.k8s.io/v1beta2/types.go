@@ -0,0 +1,242 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VerticalPodAutoscaler is the configuration for a vertical pod
+// autoscaler, which automatically manages pod resources based on
+// historical and real-time resource utilization.
+// +kubebuilder:printcolumn:name="Unsupported",type="string",JSONPath=".status.conditions[?(@.type==\"ConfigUnsupported\")].reason"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type VerticalPodAutoscaler struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Specification of the behavior of the autoscaler.
+	Spec VerticalPodAutoscalerSpec `json:"spec"`
+
+	// Current information about the autoscaler.
+	// +optional
+	Status VerticalPodAutoscalerStatus `json:"status,omitempty"`
+}
+
+// VerticalPodAutoscalerSpec is the specification of the behavior of the autoscaler.
+type VerticalPodAutoscalerSpec struct {
+	// TargetRef points to the controller managing the set of pods for the
+	// autoscaler to control - e.g. Deployment, StatefulSet.
+	TargetRef *autoscalingv1.CrossVersionObjectReference `json:"targetRef,omitempty"`
+
+	// UpdatePolicy describes the rules on how changes are applied to the pods.
+	// +optional
+	UpdatePolicy *PodUpdatePolicy `json:"updatePolicy,omitempty"`
+
+	// ResourcePolicy controls how the autoscaler computes recommended resources
+	// for individual containers.
+	// +optional
+	ResourcePolicy *PodResourcePolicy `json:"resourcePolicy,omitempty"`
+
+	// NamespaceSelector lets a single VPA target pods across every namespace
+	// whose Namespace object matches this selector, instead of only the pods
+	// in the VPA's own namespace. Mutually exclusive with TargetRef, since a
+	// targetRef already scopes the VPA to a single namespace.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// PodUpdatePolicy describes the rules on how changes are applied to the pods.
+type PodUpdatePolicy struct {
+	// UpdateMode controls when autoscaler applies changes to the pod resources.
+	// +optional
+	UpdateMode *UpdateMode `json:"updateMode,omitempty"`
+}
+
+// UpdateMode controls when autoscaler applies changes to the pod resources.
+type UpdateMode string
+
+const (
+	// UpdateModeOff means that autoscaler never changes Pod resources.
+	UpdateModeOff UpdateMode = "Off"
+	// UpdateModeAuto means that autoscaler assigns resources on pod creation
+	// and additionally can update them during the lifetime of the pod.
+	UpdateModeAuto UpdateMode = "Auto"
+)
+
+// PodResourcePolicy controls how autoscaler computes the recommended resources
+// for containers belonging to the pod.
+type PodResourcePolicy struct {
+	// Per-container resource policies.
+	// +optional
+	ContainerPolicies []ContainerResourcePolicy `json:"containerPolicies,omitempty"`
+}
+
+// ContainerResourcePolicy controls how autoscaler computes the recommended
+// resources for a specific container.
+type ContainerResourcePolicy struct {
+	// Name of the container or DefaultContainerResourcePolicy, in which
+	// case the policy is used by the containers that don't have their own
+	// policy specified.
+	ContainerName string `json:"containerName,omitempty"`
+	// Whether autoscaler is enabled for the container.
+	// +optional
+	Mode *ContainerScalingMode `json:"mode,omitempty"`
+	// MinAllowed specifies the minimal amount of resources permitted for the container.
+	// +optional
+	MinAllowed corev1.ResourceList `json:"minAllowed,omitempty"`
+	// MaxAllowed specifies the maximum amount of resources permitted for the container.
+	// +optional
+	MaxAllowed corev1.ResourceList `json:"maxAllowed,omitempty"`
+}
+
+// ContainerScalingMode controls whether autoscaler is enabled for a specific container.
+type ContainerScalingMode string
+
+const (
+	// ContainerScalingModeAuto means autoscaling is enabled for a container.
+	ContainerScalingModeAuto ContainerScalingMode = "Auto"
+	// ContainerScalingModeOff means autoscaling is disabled for a container.
+	ContainerScalingModeOff ContainerScalingMode = "Off"
+)
+
+// VerticalPodAutoscalerStatus describes the runtime state of the autoscaler.
+type VerticalPodAutoscalerStatus struct {
+	// Conditions is the set of conditions required for this autoscaler to
+	// work, and indicates whether those conditions are met.
+	// +optional
+	Conditions []VerticalPodAutoscalerCondition `json:"conditions,omitempty"`
+}
+
+// VerticalPodAutoscalerConditionType are the valid conditions of a VerticalPodAutoscaler.
+type VerticalPodAutoscalerConditionType string
+
+var (
+	// RecommendationProvided indicates whether the VPA recommender was able to
+	// calculate a recommendation.
+	RecommendationProvided VerticalPodAutoscalerConditionType = "RecommendationProvided"
+	// ConfigDeprecated indicates that this VPA configuration is deprecated
+	// and will stop being supported soon.
+	ConfigDeprecated VerticalPodAutoscalerConditionType = "ConfigDeprecated"
+	// ConfigUnsupported indicates that this VPA configuration is unsupported
+	// and recommendations will not be provided for it.
+	ConfigUnsupported VerticalPodAutoscalerConditionType = "ConfigUnsupported"
+	// ConfigOverridden indicates that a pod in scope of this VPA declared a
+	// per-container resource hint (see the vpa.k8s.io/container.* pod
+	// annotations) that overrides a bound of the VPA's own ResourcePolicy.
+	ConfigOverridden VerticalPodAutoscalerConditionType = "ConfigOverridden"
+)
+
+// VerticalPodAutoscalerCondition describes the state of a VerticalPodAutoscaler at a certain point.
+type VerticalPodAutoscalerCondition struct {
+	// Type of VerticalPodAutoscalerCondition.
+	Type VerticalPodAutoscalerConditionType `json:"type"`
+	// Status is the status of the condition (True, False, Unknown).
+	Status v1ConditionStatus `json:"status"`
+	// ObservedGeneration is the .metadata.generation of the VerticalPodAutoscaler that this
+	// condition was computed from.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Last time the condition transitioned from one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a brief, machine-readable explanation for the condition's last transition.
+	// +optional
+	Reason VerticalPodAutoscalerConditionReason `json:"reason,omitempty"`
+	// Message is a human-readable explanation containing details about the transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// VerticalPodAutoscalerConditionReason is a stable, machine-readable identifier for why a
+// VerticalPodAutoscalerCondition holds its current status. Unlike Message, its value is not
+// expected to change wording across releases, so callers should branch on Reason rather than
+// matching against Message.
+type VerticalPodAutoscalerConditionReason string
+
+const (
+	// TargetRefNotDefined indicates the VPA's targetRef did not resolve to any controller.
+	TargetRefNotDefined VerticalPodAutoscalerConditionReason = "TargetRefNotDefined"
+	// FetcherError indicates the VpaTargetSelectorFetcher returned an error while resolving targetRef.
+	FetcherError VerticalPodAutoscalerConditionReason = "FetcherError"
+	// LabelSelectorDeprecated indicates the VPA still relies on the removed, directly specified label selector.
+	LabelSelectorDeprecated VerticalPodAutoscalerConditionReason = "LabelSelectorDeprecated"
+	// BothSelectorsDefined indicates both targetRef and the deprecated label selector are set and disagree.
+	BothSelectorsDefined VerticalPodAutoscalerConditionReason = "BothSelectorsDefined"
+	// BothNamespaceScopesDefined indicates both targetRef and namespaceSelector are set.
+	BothNamespaceScopesDefined VerticalPodAutoscalerConditionReason = "BothNamespaceScopesDefined"
+	// InvalidNamespaceSelector indicates namespaceSelector failed to parse into a label selector.
+	InvalidNamespaceSelector VerticalPodAutoscalerConditionReason = "InvalidNamespaceSelector"
+	// InvalidSelector indicates a label selector expression uses an unsupported operator, or
+	// values that are incompatible with its operator (e.g. In/NotIn with no values, or
+	// Exists/DoesNotExist with values).
+	InvalidSelector VerticalPodAutoscalerConditionReason = "InvalidSelector"
+	// ContainerOverrideApplied indicates a pod in scope of this VPA overrode one of its container bounds.
+	ContainerOverrideApplied VerticalPodAutoscalerConditionReason = "ContainerOverrideApplied"
+)
+
+// v1ConditionStatus mirrors corev1.ConditionStatus without importing the whole
+// core/v1 package just for this one type.
+type v1ConditionStatus string
+
+// These are valid condition statuses.
+const (
+	ConditionTrue    v1ConditionStatus = "True"
+	ConditionFalse   v1ConditionStatus = "False"
+	ConditionUnknown v1ConditionStatus = "Unknown"
+)
+
+// VerticalPodAutoscalerConditionsMap holds VerticalPodAutoscalerCondition indexed by its type.
+type VerticalPodAutoscalerConditionsMap map[VerticalPodAutoscalerConditionType]VerticalPodAutoscalerCondition
+
+// Set updates or inserts a condition of the given type, keeping the previous
+// LastTransitionTime when the condition's Status has not changed.
+func (conditionsMap *VerticalPodAutoscalerConditionsMap) Set(
+	conditionType VerticalPodAutoscalerConditionType,
+	status bool,
+	reason VerticalPodAutoscalerConditionReason,
+	message string,
+	observedGeneration int64) *VerticalPodAutoscalerConditionsMap {
+	condition, found := (*conditionsMap)[conditionType]
+	conditionStatus := ConditionFalse
+	if status {
+		conditionStatus = ConditionTrue
+	}
+	if !found {
+		condition = VerticalPodAutoscalerCondition{
+			Type: conditionType,
+		}
+	}
+	if condition.Status != conditionStatus {
+		condition.LastTransitionTime = metav1.Now()
+	}
+	condition.Status = conditionStatus
+	condition.ObservedGeneration = observedGeneration
+	condition.Reason = reason
+	condition.Message = message
+	(*conditionsMap)[conditionType] = condition
+	return conditionsMap
+}
+
+// VerticalPodAutoscalerList is a list of VerticalPodAutoscaler objects.
+type VerticalPodAutoscalerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VerticalPodAutoscaler `json:"items"`
+}
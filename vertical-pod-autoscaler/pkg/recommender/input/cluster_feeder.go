@@ -0,0 +1,308 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package input ingests VPAs and Pods from the cluster into the recommender's model.
+package input
+
+import (
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/podannotations"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/spec"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target"
+)
+
+var (
+	unsupportedConditionNoLongerSupported          = "Label selector is no longer supported, please migrate to targetRef"
+	unsupportedConditionBothDefined                = "Both targetRef and label selector defined. Please remove label selector"
+	unsupportedConditionBothNamespaceScopesDefined = "Both targetRef and namespaceSelector defined. Please remove namespaceSelector"
+)
+
+// VerticalPodAutoscalerLister lists VerticalPodAutoscaler objects visible to the recommender.
+type VerticalPodAutoscalerLister interface {
+	List() ([]*vpa_types.VerticalPodAutoscaler, error)
+}
+
+// NamespaceLister lists Namespace objects visible to the recommender, used to resolve VPAs
+// that scope themselves to a set of namespaces via namespaceSelector rather than a single one.
+type NamespaceLister interface {
+	List() ([]*corev1.Namespace, error)
+}
+
+// ClusterStateFeeder populates a model.ClusterState from the cluster's current state.
+type ClusterStateFeeder interface {
+	// LoadVPAs updates the cluster state with the newest set of observed VPAs.
+	LoadVPAs()
+	// LoadPods updates the cluster state with the newest set of observed Pods.
+	LoadPods()
+}
+
+// clusterStateFeeder can update clusterState according to the latest observed state of the cluster.
+type clusterStateFeeder struct {
+	vpaLister       VerticalPodAutoscalerLister
+	namespaceLister NamespaceLister
+	specClient      spec.SpecClient
+	clusterState    *model.ClusterState
+
+	// selectorFetcher resolves a VPA's targetRef into the controller(s)/selector(s) it fans out to.
+	selectorFetcher target.VpaTargetSelectorFetcher
+	// legacySelectorFetcher resolves the deprecated, directly specified label selector.
+	legacySelectorFetcher target.VpaTargetSelectorFetcher
+
+	// memorySaveMode restricts tracked pods to only those in scope of some VPA.
+	memorySaveMode bool
+
+	// instanceConfig restricts VPAs and Pods ingested to those belonging to this recommender
+	// instance, letting several installations share a cluster. Disabled by default.
+	instanceConfig InstanceConfig
+}
+
+// condition describes one VerticalPodAutoscalerCondition to set (or clear) on a VPA once it is loaded.
+type condition struct {
+	conditionType vpa_types.VerticalPodAutoscalerConditionType
+	delete        bool
+	reason        vpa_types.VerticalPodAutoscalerConditionReason
+	message       string
+}
+
+// LoadVPAs fetches VPA objects and stores them, along with the controllers/selectors their
+// targetRef resolves to, in the cluster state. VPAs that don't belong to this recommender
+// instance, per feeder.instanceConfig, are skipped entirely.
+func (feeder *clusterStateFeeder) LoadVPAs() {
+	vpaCRDs, err := feeder.vpaLister.List()
+	if err != nil {
+		klog.Errorf("Cannot list VPAs. Reason: %+v", err)
+		return
+	}
+
+	for _, vpaCRD := range vpaCRDs {
+		if !feeder.instanceConfig.Matches(vpaCRD.Annotations, vpaCRD.Labels) {
+			continue
+		}
+
+		vpaID := model.VpaID{Namespace: vpaCRD.Namespace, VpaName: vpaCRD.Name}
+
+		fetches, selector, conditions := feeder.getSelectors(vpaCRD)
+		namespaceSelector, namespaceConditions := feeder.getNamespaceSelector(vpaCRD)
+		if len(namespaceConditions) > 0 {
+			// Any namespaceSelector condition (both scopes defined, or a malformed
+			// selector) means namespaceSelector can't be trusted to scope this VPA,
+			// so it shouldn't keep matching pods via its targetRef/selector either -
+			// mirrors the BothSelectorsDefined handling in getSelectors.
+			fetches, selector = nil, labels.Nothing()
+		}
+		conditions = append(conditions, namespaceConditions...)
+
+		if err := feeder.clusterState.AddOrUpdateVpa(vpaCRD, selector, fetches, namespaceSelector); err == nil {
+			storedVpa := feeder.clusterState.Vpas[vpaID]
+			for _, c := range conditions {
+				if c.delete {
+					delete(storedVpa.Conditions, c.conditionType)
+				} else {
+					storedVpa.Conditions.Set(c.conditionType, true, c.reason, c.message, vpaCRD.Generation)
+				}
+			}
+		}
+	}
+}
+
+// getNamespaceSelector validates and resolves a VPA's namespaceSelector, if any. A namespaceSelector
+// is mutually exclusive with targetRef, since targetRef already scopes the VPA to a single namespace.
+// On any validation failure, the VPA is also stripped of its PodSelector/ControllerFetches by the
+// caller, since a VPA with a malformed namespaceSelector should not match any pods.
+func (feeder *clusterStateFeeder) getNamespaceSelector(vpa *vpa_types.VerticalPodAutoscaler) (labels.Selector, []condition) {
+	if vpa.Spec.NamespaceSelector == nil {
+		return nil, nil
+	}
+	if vpa.Spec.TargetRef != nil {
+		return nil, []condition{
+			{conditionType: vpa_types.ConfigUnsupported, reason: vpa_types.BothNamespaceScopesDefined, message: unsupportedConditionBothNamespaceScopesDefined},
+		}
+	}
+	if err := validateSelectorExpressions(vpa.Spec.NamespaceSelector); err != nil {
+		return nil, []condition{
+			{conditionType: vpa_types.ConfigUnsupported, reason: vpa_types.InvalidSelector, message: fmt.Sprintf("Invalid namespaceSelector: %v", err)},
+		}
+	}
+	namespaceSelector, err := metav1.LabelSelectorAsSelector(vpa.Spec.NamespaceSelector)
+	if err != nil {
+		return nil, []condition{
+			{conditionType: vpa_types.ConfigUnsupported, reason: vpa_types.InvalidNamespaceSelector, message: fmt.Sprintf("Invalid namespaceSelector: %v", err)},
+		}
+	}
+	return namespaceSelector, nil
+}
+
+// validateSelectorExpressions rejects MatchExpressions that metav1.LabelSelectorAsSelector would
+// otherwise accept but that can never usefully match anything: unknown operators, In/NotIn with no
+// values to compare against, and Exists/DoesNotExist carrying values they ignore.
+func validateSelectorExpressions(selector *metav1.LabelSelector) error {
+	for _, expr := range selector.MatchExpressions {
+		switch expr.Operator {
+		case metav1.LabelSelectorOpIn, metav1.LabelSelectorOpNotIn:
+			if len(expr.Values) == 0 {
+				return fmt.Errorf("expression %q: operator %q requires at least one value", expr.Key, expr.Operator)
+			}
+		case metav1.LabelSelectorOpExists, metav1.LabelSelectorOpDoesNotExist:
+			if len(expr.Values) != 0 {
+				return fmt.Errorf("expression %q: operator %q must not specify values", expr.Key, expr.Operator)
+			}
+		default:
+			return fmt.Errorf("expression %q: unsupported operator %q", expr.Key, expr.Operator)
+		}
+	}
+	return nil
+}
+
+// getSelectors resolves the controllers a VPA's targetRef fans out to, falling back to the
+// deprecated label selector, and returns the conditions that should be set as a result. A
+// *target.MalformedSelectorError from either fetcher - meaning the selector it resolved could not
+// be parsed - takes priority over every other condition below, since no selector resolved by
+// either path can be trusted at that point.
+func (feeder *clusterStateFeeder) getSelectors(vpa *vpa_types.VerticalPodAutoscaler) ([]*target.ControllerFetchResult, labels.Selector, []condition) {
+	fetches, fetchErr := feeder.selectorFetcher.Fetch(vpa)
+
+	var legacySelector labels.Selector
+	legacyFetches, legacyFetchErr := feeder.legacySelectorFetcher.Fetch(vpa)
+	if len(legacyFetches) > 0 {
+		legacySelector = legacyFetches[0].Selector
+	}
+
+	var malformed *target.MalformedSelectorError
+	if errors.As(fetchErr, &malformed) || errors.As(legacyFetchErr, &malformed) {
+		return nil, labels.Nothing(), []condition{
+			{conditionType: vpa_types.ConfigUnsupported, reason: vpa_types.InvalidSelector, message: fmt.Sprintf("Invalid label selector: %v", malformed)},
+		}
+	}
+
+	if fetchErr == nil && len(fetches) > 0 {
+		if legacySelector != nil && legacySelector.String() != fetches[0].Selector.String() {
+			return nil, labels.Nothing(), []condition{
+				{conditionType: vpa_types.ConfigUnsupported, reason: vpa_types.BothSelectorsDefined, message: unsupportedConditionBothDefined},
+			}
+		}
+		return fetches, fetches[0].Selector, []condition{
+			{conditionType: vpa_types.ConfigDeprecated, delete: true},
+		}
+	}
+
+	if legacySelector != nil {
+		return nil, labels.Nothing(), []condition{
+			{conditionType: vpa_types.ConfigUnsupported, reason: vpa_types.LabelSelectorDeprecated, message: unsupportedConditionNoLongerSupported},
+		}
+	}
+
+	if fetchErr != nil {
+		return nil, labels.Nothing(), []condition{
+			{conditionType: vpa_types.ConfigUnsupported, reason: vpa_types.FetcherError, message: fmt.Sprintf("Cannot read targetRef. Reason: %v", fetchErr)},
+		}
+	}
+	return nil, labels.Nothing(), []condition{
+		{conditionType: vpa_types.ConfigUnsupported, reason: vpa_types.TargetRefNotDefined, message: "Cannot read targetRef"},
+	}
+}
+
+// LoadPods fetches the current pod specs, stores them in the cluster state, and merges any
+// vpa.k8s.io/container.* resource hints they declare for themselves into the effective
+// ResourcePolicy of the VPA(s) they are in scope of. Pods that don't belong to this recommender
+// instance, per feeder.instanceConfig, are skipped entirely.
+func (feeder *clusterStateFeeder) LoadPods() {
+	podSpecs, err := feeder.specClient.GetPodSpecs()
+	if err != nil {
+		klog.Errorf("Cannot get pod specs. Reason: %+v", err)
+		return
+	}
+
+	for _, vpa := range feeder.clusterState.Vpas {
+		vpa.ResetResourcePolicy()
+	}
+	overriddenVpas := make(map[model.VpaID]bool)
+	namespaceLabels := feeder.loadNamespaceLabels()
+
+	for _, podSpec := range podSpecs {
+		if !feeder.instanceConfig.Matches(podSpec.Annotations, podSpec.PodLabels) {
+			continue
+		}
+
+		podLabels := labels.Set(podSpec.PodLabels)
+		matchesAnyVpa := false
+		for vpaID, vpa := range feeder.clusterState.Vpas {
+			if !vpa.InScope(vpaID.Namespace, podSpec.ID.Namespace, podLabels, namespaceLabels[podSpec.ID.Namespace]) {
+				continue
+			}
+			matchesAnyVpa = true
+			if feeder.applyPodOverrides(vpa, podSpec) {
+				overriddenVpas[vpaID] = true
+			}
+		}
+		if feeder.memorySaveMode && !matchesAnyVpa {
+			continue
+		}
+		feeder.clusterState.AddOrUpdatePod(podSpec.ID, podSpec.PodLabels, podSpec.Phase)
+	}
+
+	for vpaID, vpa := range feeder.clusterState.Vpas {
+		if overriddenVpas[vpaID] {
+			vpa.Conditions.Set(vpa_types.ConfigOverridden, true, vpa_types.ContainerOverrideApplied,
+				"One or more pods in scope override a resource bound via vpa.k8s.io/container.* annotations", vpa.Generation)
+		} else {
+			delete(vpa.Conditions, vpa_types.ConfigOverridden)
+		}
+	}
+}
+
+// loadNamespaceLabels returns the labels of every Namespace visible to the recommender, keyed by
+// name, so that VPAs using namespaceSelector can be matched against the namespace a pod lives in.
+func (feeder *clusterStateFeeder) loadNamespaceLabels() map[string]labels.Set {
+	namespaceLabels := make(map[string]labels.Set)
+	if feeder.namespaceLister == nil {
+		return namespaceLabels
+	}
+	namespaces, err := feeder.namespaceLister.List()
+	if err != nil {
+		klog.Errorf("Cannot list Namespaces. Reason: %+v", err)
+		return namespaceLabels
+	}
+	for _, namespace := range namespaces {
+		namespaceLabels[namespace.Name] = labels.Set(namespace.Labels)
+	}
+	return namespaceLabels
+}
+
+// applyPodOverrides merges the resource hints podSpec declared for itself into vpa's effective
+// ResourcePolicy, returning true if doing so changed a bound away from the VPA CRD's own policy.
+func (feeder *clusterStateFeeder) applyPodOverrides(vpa *model.Vpa, podSpec *spec.BasicPodSpec) bool {
+	overrides, err := podannotations.ParsePodOverrides(podSpec.Annotations)
+	if err != nil {
+		klog.Warningf("Cannot parse container overrides for pod %v: %v", podSpec.ID, err)
+		return false
+	}
+	changed := false
+	for containerName, override := range overrides {
+		if vpa.ApplyContainerOverride(containerName, override.MinAllowed, override.MaxAllowed, override.Mode) {
+			changed = true
+		}
+	}
+	return changed
+}
@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/golang/mock/gomock"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,8 +29,13 @@ import (
 	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/spec"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target"
 	target_mock "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target/mock"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/test"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func parseLabelSelector(selector string) labels.Selector {
@@ -38,6 +44,15 @@ func parseLabelSelector(selector string) labels.Selector {
 	return parsedSelector
 }
 
+// asFetches wraps a single labels.Selector into the []*target.ControllerFetchResult
+// shape returned by target.VpaTargetSelectorFetcher.Fetch, or returns nil if selector is nil.
+func asFetches(selector labels.Selector) []*target.ControllerFetchResult {
+	if selector == nil {
+		return nil
+	}
+	return []*target.ControllerFetchResult{{Selector: selector}}
+}
+
 var (
 	unsupportedConditionNoLongerSupported = "Label selector is no longer supported, please migrate to targetRef"
 	unsupportedConditionTextFromFetcher   = "Cannot read targetRef. Reason: targetRef not defined"
@@ -51,11 +66,18 @@ func TestLegacySelector(t *testing.T) {
 		legacySelector            labels.Selector
 		selector                  labels.Selector
 		fetchSelectorError        error
+		legacyFetchError          error
 		expectedSelector          labels.Selector
 		expectedConfigUnsupported *string
+		expectedReason            vpa_types.VerticalPodAutoscalerConditionReason
 		expectedConfigDeprecated  *string
 	}
 
+	malformedFromFetch := &target.MalformedSelectorError{Expression: "tier", Err: fmt.Errorf("unsupported operator \"Invalid\"")}
+	unsupportedConditionMalformedFromFetch := fmt.Sprintf("Invalid label selector: %v", malformedFromFetch)
+	malformedFromLegacyFetch := &target.MalformedSelectorError{Expression: "env", Err: fmt.Errorf("operator \"In\" requires at least one value")}
+	unsupportedConditionMalformedFromLegacyFetch := fmt.Sprintf("Invalid label selector: %v", malformedFromLegacyFetch)
+
 	testCases := []testCase{
 		{
 			legacySelector:            nil,
@@ -63,6 +85,7 @@ func TestLegacySelector(t *testing.T) {
 			fetchSelectorError:        fmt.Errorf("targetRef not defined"),
 			expectedSelector:          labels.Nothing(),
 			expectedConfigUnsupported: &unsupportedConditionTextFromFetcher,
+			expectedReason:            vpa_types.FetcherError,
 			expectedConfigDeprecated:  nil,
 		},
 		{
@@ -71,6 +94,7 @@ func TestLegacySelector(t *testing.T) {
 			fetchSelectorError:        nil,
 			expectedSelector:          labels.Nothing(),
 			expectedConfigUnsupported: &unsupportedConditionNoExtraText,
+			expectedReason:            vpa_types.TargetRefNotDefined,
 			expectedConfigDeprecated:  nil,
 		},
 		{
@@ -79,6 +103,7 @@ func TestLegacySelector(t *testing.T) {
 			fetchSelectorError:        fmt.Errorf("targetRef not defined"),
 			expectedSelector:          labels.Nothing(),
 			expectedConfigUnsupported: &unsupportedConditionNoLongerSupported,
+			expectedReason:            vpa_types.LabelSelectorDeprecated,
 			expectedConfigDeprecated:  nil,
 		}, {
 			// the only valid option since v1beta1 removal
@@ -94,6 +119,24 @@ func TestLegacySelector(t *testing.T) {
 			fetchSelectorError:        nil,
 			expectedSelector:          labels.Nothing(),
 			expectedConfigUnsupported: &unsupportedConditionBothDefined,
+			expectedReason:            vpa_types.BothSelectorsDefined,
+			expectedConfigDeprecated:  nil,
+		}, {
+			legacySelector:            nil,
+			selector:                  nil,
+			fetchSelectorError:        malformedFromFetch,
+			expectedSelector:          labels.Nothing(),
+			expectedConfigUnsupported: &unsupportedConditionMalformedFromFetch,
+			expectedReason:            vpa_types.InvalidSelector,
+			expectedConfigDeprecated:  nil,
+		}, {
+			legacySelector:            nil,
+			selector:                  nil,
+			fetchSelectorError:        fmt.Errorf("targetRef not defined"),
+			legacyFetchError:          malformedFromLegacyFetch,
+			expectedSelector:          labels.Nothing(),
+			expectedConfigUnsupported: &unsupportedConditionMalformedFromLegacyFetch,
+			expectedReason:            vpa_types.InvalidSelector,
 			expectedConfigDeprecated:  nil,
 		},
 	}
@@ -120,11 +163,8 @@ func TestLegacySelector(t *testing.T) {
 				selectorFetcher:       targetSelectorFetcher,
 			}
 
-			// legacyTargetSelectorFetcher is called twice:
-			// - one time to determine ultimate selector
-			// - one time to check if object uses deprecated API
-			legacyTargetSelectorFetcher.EXPECT().Fetch(vpa).Times(2).Return(tc.legacySelector, nil)
-			targetSelectorFetcher.EXPECT().Fetch(vpa).Return(tc.selector, tc.fetchSelectorError)
+			legacyTargetSelectorFetcher.EXPECT().Fetch(vpa).Return(asFetches(tc.legacySelector), tc.legacyFetchError)
+			targetSelectorFetcher.EXPECT().Fetch(vpa).Return(asFetches(tc.selector), tc.fetchSelectorError)
 			clusterStateFeeder.LoadVPAs()
 
 			vpaID := model.VpaID{
@@ -151,6 +191,7 @@ func TestLegacySelector(t *testing.T) {
 			if tc.expectedConfigUnsupported != nil {
 				assert.Contains(t, storedVpa.Conditions, vpa_types.ConfigUnsupported)
 				assert.Equal(t, *tc.expectedConfigUnsupported, storedVpa.Conditions[vpa_types.ConfigUnsupported].Message)
+				assert.Equal(t, tc.expectedReason, storedVpa.Conditions[vpa_types.ConfigUnsupported].Reason)
 			} else {
 				assert.NotContains(t, storedVpa.Conditions, vpa_types.ConfigUnsupported)
 			}
@@ -159,6 +200,52 @@ func TestLegacySelector(t *testing.T) {
 	}
 }
 
+// TestLoadVPAs_ConditionObservedGenerationAndTransitionTime covers the invariants
+// VerticalPodAutoscalerConditionsMap.Set is supposed to uphold: ObservedGeneration tracks the
+// generation of the VerticalPodAutoscaler a condition was last computed from, and
+// LastTransitionTime only advances when the condition's Status actually changes.
+func TestLoadVPAs_ConditionObservedGenerationAndTransitionTime(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vpa := test.VerticalPodAutoscaler().WithName("testVpa").WithContainer("container").WithNamespace("testNamespace").Get()
+	vpa.Generation = 1
+	vpaLister := &test.VerticalPodAutoscalerListerMock{}
+	vpaLister.On("List").Return([]*vpa_types.VerticalPodAutoscaler{vpa}, nil)
+
+	legacyTargetSelectorFetcher := target_mock.NewMockVpaTargetSelectorFetcher(ctrl)
+	targetSelectorFetcher := target_mock.NewMockVpaTargetSelectorFetcher(ctrl)
+	legacyTargetSelectorFetcher.EXPECT().Fetch(vpa).Return(nil, nil).Times(3)
+	targetSelectorFetcher.EXPECT().Fetch(vpa).Return(nil, fmt.Errorf("targetRef not defined")).Times(3)
+
+	clusterState := model.NewClusterState()
+	clusterStateFeeder := clusterStateFeeder{
+		vpaLister:             vpaLister,
+		clusterState:          clusterState,
+		legacySelectorFetcher: legacyTargetSelectorFetcher,
+		selectorFetcher:       targetSelectorFetcher,
+	}
+
+	vpaID := model.VpaID{Namespace: vpa.Namespace, VpaName: vpa.Name}
+
+	clusterStateFeeder.LoadVPAs()
+	firstCondition := clusterState.Vpas[vpaID].Conditions[vpa_types.ConfigUnsupported]
+	assert.Equal(t, int64(1), firstCondition.ObservedGeneration)
+	assert.False(t, firstCondition.LastTransitionTime.IsZero())
+
+	// Same VPA, same generation, same status - LastTransitionTime must not move.
+	clusterStateFeeder.LoadVPAs()
+	secondCondition := clusterState.Vpas[vpaID].Conditions[vpa_types.ConfigUnsupported]
+	assert.Equal(t, firstCondition.LastTransitionTime, secondCondition.LastTransitionTime)
+
+	// A later generation with the same status must still update ObservedGeneration.
+	vpa.Generation = 2
+	clusterStateFeeder.LoadVPAs()
+	thirdCondition := clusterState.Vpas[vpaID].Conditions[vpa_types.ConfigUnsupported]
+	assert.Equal(t, int64(2), thirdCondition.ObservedGeneration)
+	assert.Equal(t, firstCondition.LastTransitionTime, thirdCondition.LastTransitionTime)
+}
+
 type testSpecClient struct {
 	pods []*spec.BasicPodSpec
 }
@@ -256,3 +343,271 @@ func TestClusterStateFeeder_LoadPods(t *testing.T) {
 		})
 	}
 }
+
+// TestClusterStateFeeder_LoadPods_InstanceTracking checks that pods are only accepted when they
+// identify themselves as belonging to the feeder's configured recommender instance, across all
+// three TrackingMethod variants.
+func TestClusterStateFeeder_LoadPods_InstanceTracking(t *testing.T) {
+	podWithAnnotation := &spec.BasicPodSpec{
+		ID:          model.PodID{Namespace: "default", PodName: "annotation-foo"},
+		Annotations: map[string]string{instanceIDKey: "foo"},
+	}
+	podWithMismatchedAnnotationAndLabel := &spec.BasicPodSpec{
+		ID:          model.PodID{Namespace: "default", PodName: "annotation-bar-label-foo"},
+		Annotations: map[string]string{instanceIDKey: "bar"},
+		PodLabels:   map[string]string{instanceIDKey: "foo"},
+	}
+	podWithLabel := &spec.BasicPodSpec{
+		ID:        model.PodID{Namespace: "default", PodName: "label-foo"},
+		PodLabels: map[string]string{instanceIDKey: "foo"},
+	}
+	podWithNeither := &spec.BasicPodSpec{
+		ID: model.PodID{Namespace: "default", PodName: "untracked"},
+	}
+	allPods := []*spec.BasicPodSpec{podWithAnnotation, podWithMismatchedAnnotationAndLabel, podWithLabel, podWithNeither}
+
+	for _, tc := range []struct {
+		name        string
+		method      TrackingMethod
+		trackedPods int
+	}{
+		// Only podWithAnnotation carries a matching instanceIDKey annotation.
+		{name: "annotation", method: TrackingMethodAnnotation, trackedPods: 1},
+		// podWithMismatchedAnnotationAndLabel and podWithLabel both carry a matching
+		// instanceIDKey label; annotations are ignored entirely under this method.
+		{name: "label", method: TrackingMethodLabel, trackedPods: 2},
+		// podWithAnnotation matches on its annotation; podWithLabel falls back to its label
+		// since it has no annotation; podWithMismatchedAnnotationAndLabel does NOT match even
+		// though its label agrees, because a present-but-mismatched annotation is not a
+		// fallback case.
+		{name: "annotation+label", method: TrackingMethodAnnotationAndLabel, trackedPods: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clusterState := model.NewClusterState()
+			feeder := clusterStateFeeder{
+				specClient:     &testSpecClient{pods: allPods},
+				clusterState:   clusterState,
+				instanceConfig: InstanceConfig{InstanceID: "foo", Method: tc.method},
+			}
+
+			feeder.LoadPods()
+			assert.Len(t, feeder.clusterState.Pods, tc.trackedPods)
+		})
+	}
+}
+
+// TestClusterStateFeeder_LoadPods_MultiController checks that a VPA whose targetRef fanned out to
+// several controllers tracks a pod if it matches any one of their selectors.
+func TestClusterStateFeeder_LoadPods_MultiController(t *testing.T) {
+	podLabels := []map[string]string{
+		{"owner": "deploy-a"},
+		{"owner": "deploy-b"},
+		{"owner": "deploy-c"},
+	}
+
+	clusterState := model.NewClusterState()
+	clusterState.Vpas = map[model.VpaID]*model.Vpa{
+		{VpaName: "fanout-vpa", Namespace: "default"}: {
+			ControllerFetches: []*target.ControllerFetchResult{
+				{Selector: parseLabelSelector("owner = deploy-a")},
+				{Selector: parseLabelSelector("owner = deploy-b")},
+			},
+		},
+	}
+
+	feeder := clusterStateFeeder{
+		specClient:     makeTestSpecClient(podLabels),
+		memorySaveMode: true,
+		clusterState:   clusterState,
+	}
+
+	feeder.LoadPods()
+	assert.Len(t, feeder.clusterState.Pods, 2, "expected pods matching either fanned-out selector to be tracked")
+}
+
+// TestClusterStateFeeder_LoadPods_ContainerOverrides checks that a pod's
+// vpa.k8s.io/container.* annotations are merged into its VPA's effective ResourcePolicy and
+// reported via the ConfigOverridden condition.
+func TestClusterStateFeeder_LoadPods_ContainerOverrides(t *testing.T) {
+	vpaID := model.VpaID{VpaName: "test-vpa", Namespace: "default"}
+	clusterState := model.NewClusterState()
+	clusterState.Vpas = map[model.VpaID]*model.Vpa{
+		vpaID: {
+			PodSelector: parseLabelSelector("name=vpa-pod"),
+			Conditions:  vpa_types.VerticalPodAutoscalerConditionsMap{},
+		},
+	}
+
+	feeder := clusterStateFeeder{
+		specClient: &testSpecClient{
+			pods: []*spec.BasicPodSpec{
+				{
+					ID:        model.PodID{Namespace: "default", PodName: "canary"},
+					PodLabels: map[string]string{"name": "vpa-pod"},
+					Annotations: map[string]string{
+						"vpa.k8s.io/container.app.min-cpu": "250m",
+						"vpa.k8s.io/container.app.mode":    "Off",
+					},
+				},
+			},
+		},
+		clusterState: clusterState,
+	}
+
+	feeder.LoadPods()
+
+	storedVpa := clusterState.Vpas[vpaID]
+	assert.Contains(t, storedVpa.Conditions, vpa_types.ConfigOverridden)
+	assert.Equal(t, vpa_types.ContainerOverrideApplied, storedVpa.Conditions[vpa_types.ConfigOverridden].Reason)
+
+	require.NotNil(t, storedVpa.ResourcePolicy)
+	require.Len(t, storedVpa.ResourcePolicy.ContainerPolicies, 1)
+	containerPolicy := storedVpa.ResourcePolicy.ContainerPolicies[0]
+	assert.Equal(t, "app", containerPolicy.ContainerName)
+	assert.Equal(t, resource.MustParse("250m"), containerPolicy.MinAllowed[corev1.ResourceCPU])
+	require.NotNil(t, containerPolicy.Mode)
+	assert.Equal(t, vpa_types.ContainerScalingModeOff, *containerPolicy.Mode)
+}
+
+// TestLoadVPAs_NamespaceSelector checks that a namespaceSelector is resolved and stored on the
+// VPA, that combining it with a targetRef is rejected as unsupported, and that a namespaceSelector
+// with an invalid MatchExpression is rejected with reason InvalidSelector and strips the VPA's
+// PodSelector down to labels.Nothing(). namespaceSelector is the only directly user-specified
+// metav1.LabelSelector left on VerticalPodAutoscalerSpec - the deprecated label selector is
+// resolved through the opaque VpaTargetSelectorFetcher interface instead - so it's exercised here
+// rather than on the legacy-selector path covered by TestLegacySelector.
+func TestLoadVPAs_NamespaceSelector(t *testing.T) {
+	unsupportedBothNamespaceScopes := "Both targetRef and namespaceSelector defined. Please remove namespaceSelector"
+
+	invalidOperator := "Invalid namespaceSelector: expression \"team\": unsupported operator \"xxx\""
+	emptyInValues := "Invalid namespaceSelector: expression \"team\": operator \"In\" requires at least one value"
+	valuesOnExists := "Invalid namespaceSelector: expression \"team\": operator \"Exists\" must not specify values"
+
+	testCases := []struct {
+		name                      string
+		targetRef                 *autoscalingv1.CrossVersionObjectReference
+		namespaceSelector         *metav1.LabelSelector
+		expectedNamespaceSelector labels.Selector
+		expectedConfigUnsupported *string
+		expectedReason            vpa_types.VerticalPodAutoscalerConditionReason
+		expectPodSelectorNothing  bool
+	}{
+		{
+			name:                      "namespaceSelector only",
+			namespaceSelector:         &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+			expectedNamespaceSelector: parseLabelSelector("team = payments"),
+		},
+		{
+			name:                      "targetRef and namespaceSelector both defined",
+			targetRef:                 &autoscalingv1.CrossVersionObjectReference{Kind: "Deployment", Name: "app"},
+			namespaceSelector:         &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+			expectedConfigUnsupported: &unsupportedBothNamespaceScopes,
+			expectedReason:            vpa_types.BothNamespaceScopesDefined,
+			expectPodSelectorNothing:  true,
+		},
+		{
+			name: "unknown operator",
+			namespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "team", Operator: "xxx"},
+			}},
+			expectedConfigUnsupported: &invalidOperator,
+			expectedReason:            vpa_types.InvalidSelector,
+			expectPodSelectorNothing:  true,
+		},
+		{
+			name: "In with no values",
+			namespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "team", Operator: metav1.LabelSelectorOpIn, Values: []string{}},
+			}},
+			expectedConfigUnsupported: &emptyInValues,
+			expectedReason:            vpa_types.InvalidSelector,
+			expectPodSelectorNothing:  true,
+		},
+		{
+			name: "Exists with values",
+			namespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "team", Operator: metav1.LabelSelectorOpExists, Values: []string{"payments"}},
+			}},
+			expectedConfigUnsupported: &valuesOnExists,
+			expectedReason:            vpa_types.InvalidSelector,
+			expectPodSelectorNothing:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			vpa := test.VerticalPodAutoscaler().WithName("testVpa").WithNamespace("testNamespace").
+				WithTargetRef(tc.targetRef).WithNamespaceSelector(tc.namespaceSelector).Get()
+			vpaLister := &test.VerticalPodAutoscalerListerMock{}
+			vpaLister.On("List").Return([]*vpa_types.VerticalPodAutoscaler{vpa}, nil)
+
+			legacyTargetSelectorFetcher := target_mock.NewMockVpaTargetSelectorFetcher(ctrl)
+			targetSelectorFetcher := target_mock.NewMockVpaTargetSelectorFetcher(ctrl)
+			legacyTargetSelectorFetcher.EXPECT().Fetch(vpa).Return(nil, nil)
+			targetSelectorFetcher.EXPECT().Fetch(vpa).Return(nil, fmt.Errorf("targetRef not defined"))
+
+			clusterState := model.NewClusterState()
+			feeder := clusterStateFeeder{
+				vpaLister:             vpaLister,
+				clusterState:          clusterState,
+				legacySelectorFetcher: legacyTargetSelectorFetcher,
+				selectorFetcher:       targetSelectorFetcher,
+			}
+			feeder.LoadVPAs()
+
+			storedVpa := clusterState.Vpas[model.VpaID{Namespace: vpa.Namespace, VpaName: vpa.Name}]
+			if tc.expectedNamespaceSelector != nil {
+				require.NotNil(t, storedVpa.NamespaceSelector)
+				assert.Equal(t, tc.expectedNamespaceSelector.String(), storedVpa.NamespaceSelector.String())
+			} else {
+				assert.Nil(t, storedVpa.NamespaceSelector)
+			}
+			if tc.expectedConfigUnsupported != nil {
+				assert.Contains(t, storedVpa.Conditions, vpa_types.ConfigUnsupported)
+				assert.Equal(t, *tc.expectedConfigUnsupported, storedVpa.Conditions[vpa_types.ConfigUnsupported].Message)
+				assert.Equal(t, tc.expectedReason, storedVpa.Conditions[vpa_types.ConfigUnsupported].Reason)
+			}
+			if tc.expectPodSelectorNothing {
+				require.NotNil(t, storedVpa.PodSelector)
+				assert.Equal(t, labels.Nothing().String(), storedVpa.PodSelector.String())
+			}
+		})
+	}
+}
+
+// TestClusterStateFeeder_LoadPods_NamespaceSelector checks that a VPA with a namespaceSelector
+// tracks pods across every namespace whose Namespace object matches it.
+func TestClusterStateFeeder_LoadPods_NamespaceSelector(t *testing.T) {
+	clusterState := model.NewClusterState()
+	clusterState.Vpas = map[model.VpaID]*model.Vpa{
+		{VpaName: "fleet-vpa", Namespace: "platform"}: {
+			PodSelector:       labels.Everything(),
+			NamespaceSelector: parseLabelSelector("team = payments"),
+		},
+	}
+
+	namespaceLister := &test.NamespaceListerMock{}
+	namespaceLister.On("List").Return([]*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "payments-a", Labels: map[string]string{"team": "payments"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other-team", Labels: map[string]string{"team": "checkout"}}},
+	}, nil)
+
+	feeder := clusterStateFeeder{
+		specClient: &testSpecClient{
+			pods: []*spec.BasicPodSpec{
+				{ID: model.PodID{Namespace: "payments-a", PodName: "pod-1"}, PodLabels: map[string]string{}},
+				{ID: model.PodID{Namespace: "other-team", PodName: "pod-2"}, PodLabels: map[string]string{}},
+			},
+		},
+		namespaceLister: namespaceLister,
+		memorySaveMode:  true,
+		clusterState:    clusterState,
+	}
+
+	feeder.LoadPods()
+	assert.Len(t, feeder.clusterState.Pods, 1, "expected only the pod in a matching namespace to be tracked")
+	assert.Contains(t, feeder.clusterState.Pods, model.PodID{Namespace: "payments-a", PodName: "pod-1"})
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package podannotations reads per-container resource hints that a pod pins for itself via
+// annotations, e.g. for canaries or one-off jobs that share a VPA with a Deployment but still
+// want to nudge their own bounds without forking the VPA object.
+package podannotations
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+)
+
+// annotationPrefix namespaces the per-container resource hints a pod can declare, of the form
+// "vpa.k8s.io/container.<name>.<bound>", e.g. "vpa.k8s.io/container.app.min-cpu".
+const annotationPrefix = "vpa.k8s.io/container."
+
+// ContainerOverride holds the resource hints a pod pinned for one of its containers.
+type ContainerOverride struct {
+	MinAllowed corev1.ResourceList
+	MaxAllowed corev1.ResourceList
+	Mode       *vpa_types.ContainerScalingMode
+}
+
+// ParsePodOverrides extracts the per-container resource overrides a pod declared for itself,
+// keyed by container name, from annotations of the form "vpa.k8s.io/container.<name>.<bound>"
+// where <bound> is one of min-cpu, max-cpu, min-memory, max-memory or mode.
+func ParsePodOverrides(podAnnotations map[string]string) (map[string]*ContainerOverride, error) {
+	overrides := make(map[string]*ContainerOverride)
+	for key, value := range podAnnotations {
+		if !strings.HasPrefix(key, annotationPrefix) {
+			continue
+		}
+		containerName, bound, ok := strings.Cut(strings.TrimPrefix(key, annotationPrefix), ".")
+		if !ok {
+			return nil, fmt.Errorf("malformed container override annotation %q: expected %s<container>.<bound>", key, annotationPrefix)
+		}
+		override, exists := overrides[containerName]
+		if !exists {
+			override = &ContainerOverride{}
+			overrides[containerName] = override
+		}
+		if err := override.apply(bound, value); err != nil {
+			return nil, fmt.Errorf("container override annotation %q: %v", key, err)
+		}
+	}
+	return overrides, nil
+}
+
+func (override *ContainerOverride) apply(bound, value string) error {
+	if bound == "mode" {
+		mode := vpa_types.ContainerScalingMode(value)
+		if mode != vpa_types.ContainerScalingModeAuto && mode != vpa_types.ContainerScalingModeOff {
+			return fmt.Errorf("unknown mode %q", value)
+		}
+		override.Mode = &mode
+		return nil
+	}
+
+	resourceName, isMax, err := parseBoundName(bound)
+	if err != nil {
+		return err
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return fmt.Errorf("invalid quantity %q: %v", value, err)
+	}
+	list := &override.MinAllowed
+	if isMax {
+		list = &override.MaxAllowed
+	}
+	if *list == nil {
+		*list = corev1.ResourceList{}
+	}
+	(*list)[resourceName] = quantity
+	return nil
+}
+
+func parseBoundName(bound string) (resourceName corev1.ResourceName, isMax bool, err error) {
+	switch bound {
+	case "min-cpu":
+		return corev1.ResourceCPU, false, nil
+	case "max-cpu":
+		return corev1.ResourceCPU, true, nil
+	case "min-memory":
+		return corev1.ResourceMemory, false, nil
+	case "max-memory":
+		return corev1.ResourceMemory, true, nil
+	default:
+		return "", false, fmt.Errorf("unknown bound %q", bound)
+	}
+}
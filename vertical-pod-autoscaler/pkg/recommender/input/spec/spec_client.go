@@ -0,0 +1,41 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package spec provides simplified Pod specifications used by the recommender.
+package spec
+
+import (
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+)
+
+// BasicPodSpec holds the minimal Pod state the recommender needs.
+type BasicPodSpec struct {
+	// ID identifies the pod.
+	ID model.PodID
+	// PodLabels are the labels of the pod.
+	PodLabels map[string]string
+	// Annotations are the annotations of the pod, including any
+	// vpa.k8s.io/container.* resource override hints it declared for itself.
+	Annotations map[string]string
+	// Phase is the current lifecycle phase of the pod.
+	Phase string
+}
+
+// SpecClient provides Pod specifications to feed into the cluster model.
+type SpecClient interface {
+	// GetPodSpecs returns the specifications of all Pods currently visible to the recommender.
+	GetPodSpecs() ([]*BasicPodSpec, error)
+}
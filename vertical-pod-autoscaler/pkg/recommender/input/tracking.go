@@ -0,0 +1,66 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package input
+
+// instanceIDKey is the annotation and label key carrying the identifier of the recommender
+// installation a VPA or Pod belongs to, used to let several installations share a cluster.
+const instanceIDKey = "autoscaling.k8s.io/instance"
+
+// TrackingMethod selects which of a VPA's or Pod's annotations/labels InstanceConfig consults
+// to decide whether it belongs to this recommender instance.
+type TrackingMethod string
+
+const (
+	// TrackingMethodAnnotation matches objects carrying a matching instanceIDKey annotation.
+	TrackingMethodAnnotation TrackingMethod = "annotation"
+	// TrackingMethodLabel matches objects carrying a matching instanceIDKey label.
+	TrackingMethodLabel TrackingMethod = "label"
+	// TrackingMethodAnnotationAndLabel matches objects carrying a matching instanceIDKey
+	// annotation, falling back to the instanceIDKey label when the annotation is absent.
+	TrackingMethodAnnotationAndLabel TrackingMethod = "annotation+label"
+)
+
+// InstanceConfig configures instance tracking for a clusterStateFeeder. When InstanceID is
+// non-empty, the feeder only ingests VPAs and accepts Pods carrying an instanceIDKey
+// annotation/label (per Method) equal to InstanceID, so that several VPA installations can
+// share a cluster without reacting to objects that belong to one another. A zero-value
+// InstanceConfig disables tracking and accepts every object.
+type InstanceConfig struct {
+	InstanceID string
+	Method     TrackingMethod
+}
+
+// Matches reports whether annotations/labels identify their owning object as belonging to this
+// instance. It always returns true when tracking is disabled (InstanceID is empty).
+func (c InstanceConfig) Matches(annotations, labels map[string]string) bool {
+	if c.InstanceID == "" {
+		return true
+	}
+	method := c.Method
+	if method == "" {
+		method = TrackingMethodAnnotation
+	}
+	if method == TrackingMethodAnnotation || method == TrackingMethodAnnotationAndLabel {
+		if value, ok := annotations[instanceIDKey]; ok {
+			return value == c.InstanceID
+		}
+		if method == TrackingMethodAnnotation {
+			return false
+		}
+	}
+	return labels[instanceIDKey] == c.InstanceID
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target"
+)
+
+// ClusterState tracks the recommender's latest view of VPAs and Pods observed in the cluster.
+type ClusterState struct {
+	Vpas map[VpaID]*Vpa
+	Pods map[PodID]*PodState
+}
+
+// NewClusterState returns a new ClusterState with empty maps.
+func NewClusterState() *ClusterState {
+	return &ClusterState{
+		Vpas: make(map[VpaID]*Vpa),
+		Pods: make(map[PodID]*PodState),
+	}
+}
+
+// AddOrUpdateVpa stores or refreshes the state of a single VPA, along with the
+// controllers/selectors its targetRef currently resolves to and, if set, the
+// namespaceSelector it uses to match pods across namespaces.
+func (cs *ClusterState) AddOrUpdateVpa(apiObject *vpa_types.VerticalPodAutoscaler, selector labels.Selector, controllerFetches []*target.ControllerFetchResult, namespaceSelector labels.Selector) error {
+	id := VpaID{Namespace: apiObject.Namespace, VpaName: apiObject.Name}
+	vpa, exists := cs.Vpas[id]
+	if !exists {
+		vpa = NewVpa(id)
+		cs.Vpas[id] = vpa
+	}
+	vpa.Generation = apiObject.Generation
+	vpa.PodSelector = selector
+	vpa.ControllerFetches = controllerFetches
+	vpa.NamespaceSelector = namespaceSelector
+	vpa.BaseResourcePolicy = apiObject.Spec.ResourcePolicy
+	vpa.ResetResourcePolicy()
+	return nil
+}
+
+// AddOrUpdatePod stores or refreshes the observed state of a single pod.
+func (cs *ClusterState) AddOrUpdatePod(id PodID, podLabels map[string]string, phase string) {
+	pod, exists := cs.Pods[id]
+	if !exists {
+		pod = &PodState{ID: id}
+		cs.Pods[id] = pod
+	}
+	pod.Labels = podLabels
+	pod.Phase = phase
+}
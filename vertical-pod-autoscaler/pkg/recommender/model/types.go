@@ -0,0 +1,36 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+// VpaID contains unique identifier of a VPA object.
+type VpaID struct {
+	Namespace string
+	VpaName   string
+}
+
+// PodID contains unique identifier of a Pod.
+type PodID struct {
+	Namespace string
+	PodName   string
+}
+
+// PodState holds the data we keep about a pod between reconciliation loops.
+type PodState struct {
+	ID     PodID
+	Labels map[string]string
+	Phase  string
+}
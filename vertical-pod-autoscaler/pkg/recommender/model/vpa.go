@@ -0,0 +1,180 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target"
+)
+
+// Vpa holds the recommender's view of a single VerticalPodAutoscaler object:
+// which pods it is in scope for and what conditions it currently reports.
+type Vpa struct {
+	ID VpaID
+
+	// Generation is the .metadata.generation of the last VerticalPodAutoscaler object this Vpa
+	// was updated from, recorded on its Conditions so consumers can tell how stale they are.
+	Generation int64
+
+	// PodSelector is the selector of the first controller this VPA's targetRef
+	// resolved to. Kept for backwards compatibility with callers that only
+	// care about a single selector; VPAs whose targetRef fans out to more than
+	// one controller should match pods through ControllerFetches instead.
+	PodSelector labels.Selector
+
+	// ControllerFetches holds one (controllerRef, selector) pair per controller
+	// this VPA's targetRef fanned out to. A targetRef pointing directly at a
+	// single Deployment/StatefulSet/etc. populates exactly one entry.
+	ControllerFetches []*target.ControllerFetchResult
+
+	// NamespaceSelector, when set, lets this VPA match pods across every namespace whose
+	// Namespace object satisfies it, instead of only pods in vpa.ID.Namespace.
+	NamespaceSelector labels.Selector
+
+	// BaseResourcePolicy is the ResourcePolicy as configured directly on the VPA CRD.
+	BaseResourcePolicy *vpa_types.PodResourcePolicy
+	// ResourcePolicy is the effective policy for this VPA: BaseResourcePolicy with any
+	// pod-declared container overrides (see ApplyContainerOverride) merged in for the
+	// current LoadPods cycle. Recomputed from scratch via ResetResourcePolicy on every cycle.
+	ResourcePolicy *vpa_types.PodResourcePolicy
+
+	Conditions vpa_types.VerticalPodAutoscalerConditionsMap
+}
+
+// NewVpa returns a new Vpa with empty state for the given id.
+func NewVpa(id VpaID) *Vpa {
+	return &Vpa{
+		ID:         id,
+		Conditions: vpa_types.VerticalPodAutoscalerConditionsMap{},
+	}
+}
+
+// UsesSelector reports whether podLabels are in scope of this VPA - i.e. they
+// match at least one of the selectors its targetRef fanned out to.
+func (vpa *Vpa) UsesSelector(podLabels labels.Labels) bool {
+	if len(vpa.ControllerFetches) > 0 {
+		for _, fetch := range vpa.ControllerFetches {
+			if fetch.Selector != nil && fetch.Selector.Matches(podLabels) {
+				return true
+			}
+		}
+		return false
+	}
+	return vpa.PodSelector != nil && vpa.PodSelector.Matches(podLabels)
+}
+
+// InScope reports whether a pod is in scope of this VPA: its namespace and labels must match,
+// where the namespace check is vpaNamespace == podNamespace unless NamespaceSelector is set, in
+// which case namespaceLabels (the labels of the pod's Namespace object) must satisfy it instead.
+// vpaNamespace is passed in rather than read from vpa.ID so this check stays correct regardless of
+// whether the caller populated ID - the caller's map key is the authority on a Vpa's namespace.
+func (vpa *Vpa) InScope(vpaNamespace, podNamespace string, podLabels, namespaceLabels labels.Labels) bool {
+	if vpa.NamespaceSelector != nil {
+		if !vpa.NamespaceSelector.Matches(namespaceLabels) {
+			return false
+		}
+	} else if vpaNamespace != podNamespace {
+		return false
+	}
+	return vpa.UsesSelector(podLabels)
+}
+
+// ResetResourcePolicy reinitializes the effective ResourcePolicy to a fresh copy of
+// BaseResourcePolicy, discarding any pod overrides merged in during a previous cycle.
+func (vpa *Vpa) ResetResourcePolicy() {
+	vpa.ResourcePolicy = copyResourcePolicy(vpa.BaseResourcePolicy)
+}
+
+// ApplyContainerOverride merges a pod-declared resource hint for containerName into the
+// effective ResourcePolicy, returning true if doing so changed a bound away from what
+// BaseResourcePolicy specified.
+func (vpa *Vpa) ApplyContainerOverride(containerName string, minAllowed, maxAllowed corev1.ResourceList, mode *vpa_types.ContainerScalingMode) bool {
+	policy := vpa.containerPolicy(containerName)
+	changed := false
+	if mode != nil && (policy.Mode == nil || *policy.Mode != *mode) {
+		policy.Mode = mode
+		changed = true
+	}
+	if mergeResourceList(&policy.MinAllowed, minAllowed) {
+		changed = true
+	}
+	if mergeResourceList(&policy.MaxAllowed, maxAllowed) {
+		changed = true
+	}
+	return changed
+}
+
+// containerPolicy returns the effective ContainerResourcePolicy for containerName, creating an
+// empty one if BaseResourcePolicy didn't already define one for this container.
+func (vpa *Vpa) containerPolicy(containerName string) *vpa_types.ContainerResourcePolicy {
+	if vpa.ResourcePolicy == nil {
+		vpa.ResourcePolicy = &vpa_types.PodResourcePolicy{}
+	}
+	for i := range vpa.ResourcePolicy.ContainerPolicies {
+		if vpa.ResourcePolicy.ContainerPolicies[i].ContainerName == containerName {
+			return &vpa.ResourcePolicy.ContainerPolicies[i]
+		}
+	}
+	vpa.ResourcePolicy.ContainerPolicies = append(vpa.ResourcePolicy.ContainerPolicies, vpa_types.ContainerResourcePolicy{ContainerName: containerName})
+	return &vpa.ResourcePolicy.ContainerPolicies[len(vpa.ResourcePolicy.ContainerPolicies)-1]
+}
+
+func copyResourcePolicy(policy *vpa_types.PodResourcePolicy) *vpa_types.PodResourcePolicy {
+	if policy == nil {
+		return &vpa_types.PodResourcePolicy{}
+	}
+	copied := &vpa_types.PodResourcePolicy{
+		ContainerPolicies: make([]vpa_types.ContainerResourcePolicy, len(policy.ContainerPolicies)),
+	}
+	for i, containerPolicy := range policy.ContainerPolicies {
+		containerPolicy.MinAllowed = copyResourceList(containerPolicy.MinAllowed)
+		containerPolicy.MaxAllowed = copyResourceList(containerPolicy.MaxAllowed)
+		copied.ContainerPolicies[i] = containerPolicy
+	}
+	return copied
+}
+
+func copyResourceList(list corev1.ResourceList) corev1.ResourceList {
+	if list == nil {
+		return nil
+	}
+	out := make(corev1.ResourceList, len(list))
+	for name, quantity := range list {
+		out[name] = quantity
+	}
+	return out
+}
+
+func mergeResourceList(dst *corev1.ResourceList, src corev1.ResourceList) bool {
+	if len(src) == 0 {
+		return false
+	}
+	changed := false
+	if *dst == nil {
+		*dst = corev1.ResourceList{}
+	}
+	for name, quantity := range src {
+		if existing, ok := (*dst)[name]; !ok || existing.Cmp(quantity) != 0 {
+			(*dst)[name] = quantity
+			changed = true
+		}
+	}
+	return changed
+}
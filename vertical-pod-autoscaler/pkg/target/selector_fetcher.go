@@ -0,0 +1,69 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package target
+
+import (
+	"fmt"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+)
+
+// ControllerFetchResult pairs one controller targeted by a VPA with the label
+// selector used to match the pods it owns. Most VPAs resolve to a single
+// controller, but a targetRef can fan out to several - for example a custom
+// parent CRD that owns several Deployments, or a wildcard `kind: *` targetRef
+// matched by label.
+type ControllerFetchResult struct {
+	// ControllerRef identifies the controller that owns the matched pods.
+	ControllerRef autoscalingv1.CrossVersionObjectReference
+	// Selector matches the pods owned by ControllerRef.
+	Selector labels.Selector
+}
+
+// VpaTargetSelectorFetcher gets the controllers targeted by a VPA's targetRef,
+// together with the labels.Selector used to gather the Pods each of them owns.
+type VpaTargetSelectorFetcher interface {
+	// Fetch returns one ControllerFetchResult per controller the VPA's
+	// targetRef resolves to. A targetRef pointing directly at a single
+	// Deployment/StatefulSet/etc. resolves to exactly one result. Fetch returns a
+	// *MalformedSelectorError if the controller's label selector itself is invalid.
+	Fetch(vpa *vpa_types.VerticalPodAutoscaler) ([]*ControllerFetchResult, error)
+}
+
+// MalformedSelectorError is returned by Fetch when the label selector it resolved from a
+// controller (or, for legacy fetchers, from the VPA's deprecated selector field) could not be
+// converted into a labels.Selector: one of its match expressions uses an unsupported operator, or
+// a value count incompatible with its operator (e.g. In/NotIn with no values, or
+// Exists/DoesNotExist with values).
+type MalformedSelectorError struct {
+	// Expression identifies the invalid match expression, typically by its key.
+	Expression string
+	// Err is the underlying validation failure.
+	Err error
+}
+
+func (e *MalformedSelectorError) Error() string {
+	return fmt.Sprintf("expression %q: %v", e.Expression, e.Err)
+}
+
+// Unwrap lets errors.As/errors.Is see through to Err.
+func (e *MalformedSelectorError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package test holds shared builders and mocks used across the recommender's test suites.
+package test
+
+import (
+	"github.com/stretchr/testify/mock"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+)
+
+// VerticalPodAutoscalerBuilder builds test instances of VerticalPodAutoscaler.
+type VerticalPodAutoscalerBuilder interface {
+	WithName(vpaName string) VerticalPodAutoscalerBuilder
+	WithNamespace(namespace string) VerticalPodAutoscalerBuilder
+	WithContainer(containerName string) VerticalPodAutoscalerBuilder
+	WithTargetRef(targetRef *autoscalingv1.CrossVersionObjectReference) VerticalPodAutoscalerBuilder
+	WithNamespaceSelector(namespaceSelector *metav1.LabelSelector) VerticalPodAutoscalerBuilder
+	Get() *vpa_types.VerticalPodAutoscaler
+}
+
+// VerticalPodAutoscaler returns a new VerticalPodAutoscalerBuilder.
+func VerticalPodAutoscaler() VerticalPodAutoscalerBuilder {
+	return &verticalPodAutoscalerBuilder{}
+}
+
+type verticalPodAutoscalerBuilder struct {
+	vpaName           string
+	namespace         string
+	containerNames    []string
+	targetRef         *autoscalingv1.CrossVersionObjectReference
+	namespaceSelector *metav1.LabelSelector
+}
+
+func (b *verticalPodAutoscalerBuilder) WithName(vpaName string) VerticalPodAutoscalerBuilder {
+	r := *b
+	r.vpaName = vpaName
+	return &r
+}
+
+func (b *verticalPodAutoscalerBuilder) WithNamespace(namespace string) VerticalPodAutoscalerBuilder {
+	r := *b
+	r.namespace = namespace
+	return &r
+}
+
+func (b *verticalPodAutoscalerBuilder) WithContainer(containerName string) VerticalPodAutoscalerBuilder {
+	r := *b
+	r.containerNames = append(append([]string{}, r.containerNames...), containerName)
+	return &r
+}
+
+func (b *verticalPodAutoscalerBuilder) WithTargetRef(targetRef *autoscalingv1.CrossVersionObjectReference) VerticalPodAutoscalerBuilder {
+	r := *b
+	r.targetRef = targetRef
+	return &r
+}
+
+func (b *verticalPodAutoscalerBuilder) WithNamespaceSelector(namespaceSelector *metav1.LabelSelector) VerticalPodAutoscalerBuilder {
+	r := *b
+	r.namespaceSelector = namespaceSelector
+	return &r
+}
+
+func (b *verticalPodAutoscalerBuilder) Get() *vpa_types.VerticalPodAutoscaler {
+	containerPolicies := make([]vpa_types.ContainerResourcePolicy, len(b.containerNames))
+	for i, name := range b.containerNames {
+		containerPolicies[i] = vpa_types.ContainerResourcePolicy{ContainerName: name}
+	}
+	return &vpa_types.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.vpaName,
+			Namespace: b.namespace,
+		},
+		Spec: vpa_types.VerticalPodAutoscalerSpec{
+			TargetRef:         b.targetRef,
+			NamespaceSelector: b.namespaceSelector,
+			ResourcePolicy:    &vpa_types.PodResourcePolicy{ContainerPolicies: containerPolicies},
+		},
+	}
+}
+
+// VerticalPodAutoscalerListerMock is a testify-based mock of the VerticalPodAutoscalerLister interface.
+type VerticalPodAutoscalerListerMock struct {
+	mock.Mock
+}
+
+// List mocks base method.
+func (m *VerticalPodAutoscalerListerMock) List() ([]*vpa_types.VerticalPodAutoscaler, error) {
+	args := m.Called()
+	var result []*vpa_types.VerticalPodAutoscaler
+	if args.Get(0) != nil {
+		result = args.Get(0).([]*vpa_types.VerticalPodAutoscaler)
+	}
+	return result, args.Error(1)
+}
+
+// NamespaceListerMock is a testify-based mock of the NamespaceLister interface.
+type NamespaceListerMock struct {
+	mock.Mock
+}
+
+// List mocks base method.
+func (m *NamespaceListerMock) List() ([]*corev1.Namespace, error) {
+	args := m.Called()
+	var result []*corev1.Namespace
+	if args.Get(0) != nil {
+		result = args.Get(0).([]*corev1.Namespace)
+	}
+	return result, args.Error(1)
+}